@@ -23,6 +23,13 @@ type MaxMinValue interface {
 	Value() int64
 }
 
+// namedValue is satisfied by any named, read-only int64 value; it lets
+// WriteTo display gaugeImpl and funcGauge uniformly.
+type namedValue interface {
+	Name() string
+	Value() int64
+}
+
 // Counter is an interface for integer increase only counter.
 type Counter interface {
 	// Increment increases counter by one.
@@ -42,16 +49,70 @@ type CounterBox struct {
 	min      map[string]*minImpl
 	max      map[string]*maxImpl
 	m        *sync.RWMutex
+
+	namespace string
+	subsystem string
+
+	helpM       sync.RWMutex
+	counterHelp map[string]string
+	minHelp     map[string]string
+	maxHelp     map[string]string
+
+	counterVecs map[string]*CounterVec
+	maxVecs     map[string]*MaxVec
+	minVecs     map[string]*MinVec
+
+	floatCounters map[string]*floatCounterImpl
+	histograms    map[string]*Histogram
+	rateCounters  map[string]*rateCounterImpl
+
+	gauges     map[string]*gaugeImpl
+	funcGauges map[string]*funcGauge
+}
+
+// Option configures a CounterBox at construction time.
+type Option func(*CounterBox)
+
+// WithNamespace sets a namespace prepended to every metric name exposed
+// through the Prometheus handler, e.g. "myapp" turns "requests" into
+// "myapp_requests".
+func WithNamespace(namespace string) Option {
+	return func(c *CounterBox) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets a subsystem prepended to every metric name exposed
+// through the Prometheus handler, after the namespace if any.
+func WithSubsystem(subsystem string) Option {
+	return func(c *CounterBox) {
+		c.subsystem = subsystem
+	}
 }
 
 // NewCounterBox creates a new object to keep all counters.
-func NewCounterBox() *CounterBox {
-	return &CounterBox{
-		counters: make(map[string]*counterImpl),
-		min:      make(map[string]*minImpl),
-		max:      make(map[string]*maxImpl),
-		m:        &sync.RWMutex{},
+func NewCounterBox(opts ...Option) *CounterBox {
+	c := &CounterBox{
+		counters:      make(map[string]*counterImpl),
+		min:           make(map[string]*minImpl),
+		max:           make(map[string]*maxImpl),
+		m:             &sync.RWMutex{},
+		counterHelp:   make(map[string]string),
+		minHelp:       make(map[string]string),
+		maxHelp:       make(map[string]string),
+		counterVecs:   make(map[string]*CounterVec),
+		maxVecs:       make(map[string]*MaxVec),
+		minVecs:       make(map[string]*MinVec),
+		floatCounters: make(map[string]*floatCounterImpl),
+		histograms:    make(map[string]*Histogram),
+		rateCounters:  make(map[string]*rateCounterImpl),
+		gauges:        make(map[string]*gaugeImpl),
+		funcGauges:    make(map[string]*funcGauge),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // CreateHttpHandler creates a simple handler printing values of all counters.
@@ -71,6 +132,43 @@ func (c *CounterBox) CreateHttpHandler() http.HandlerFunc {
 		for k, v := range c.min {
 			fmt.Fprintf(w, "%s=%d\n", k, v.Value())
 		}
+		fmt.Fprintf(w, "\nFloat counters %d\n", len(c.floatCounters))
+		for k, v := range c.floatCounters {
+			fmt.Fprintf(w, "%s=%v\n", k, v.Value())
+		}
+		fmt.Fprintf(w, "\nHistograms %d\n", len(c.histograms))
+		for _, v := range c.histograms {
+			writeHistogramPlain(w, v)
+		}
+		fmt.Fprintf(w, "\nRate counters %d\n", len(c.rateCounters))
+		for k, v := range c.rateCounters {
+			fmt.Fprintf(w, "%s=%d (%.2f/s)\n", k, v.Value(), v.RatePerSecond())
+		}
+		fmt.Fprintf(w, "\nGauges %d\n", len(c.gauges)+len(c.funcGauges))
+		for k, v := range c.gauges {
+			fmt.Fprintf(w, "%s=%d\n", k, v.Value())
+		}
+		for k, v := range c.funcGauges {
+			fmt.Fprintf(w, "%s=%d\n", k, v.Value())
+		}
+		for _, v := range c.counterVecs {
+			fmt.Fprintf(w, "\nCounter vector %s\n", v.Name())
+			for _, e := range v.Entries() {
+				fmt.Fprintf(w, "%s=%d\n", formatVecLabels(v.Name(), e.Labels), e.Value)
+			}
+		}
+		for _, v := range c.maxVecs {
+			fmt.Fprintf(w, "\nMax vector %s\n", v.Name())
+			for _, e := range v.Entries() {
+				fmt.Fprintf(w, "%s=%d\n", formatVecLabels(v.Name(), e.Labels), e.Value)
+			}
+		}
+		for _, v := range c.minVecs {
+			fmt.Fprintf(w, "\nMin vector %s\n", v.Name())
+			for _, e := range v.Entries() {
+				fmt.Fprintf(w, "%s=%d\n", formatVecLabels(v.Name(), e.Labels), e.Value)
+			}
+		}
 	}
 }
 
@@ -84,7 +182,15 @@ func (c *CounterBox) GetCounter(name string) Counter {
 	c.m.RUnlock()
 	c.m.Lock()
 	defer c.m.Unlock()
+	return c.getOrCreateCounterLocked(name)
+}
 
+// getOrCreateCounterLocked returns the counter of given name, creating it
+// if necessary. Callers must hold c.m for writing.
+func (c *CounterBox) getOrCreateCounterLocked(name string) *counterImpl {
+	if v, ok := c.counters[name]; ok {
+		return v
+	}
 	v := &counterImpl{name, 0}
 	c.counters[name] = v
 	return v
@@ -122,6 +228,33 @@ func (c *CounterBox) GetMax(name string) MaxMinValue {
 	return v
 }
 
+// GetCounterWithHelp is like GetCounter, but additionally registers a help
+// string for the counter to be surfaced as a "# HELP" line by WriteProm.
+func (c *CounterBox) GetCounterWithHelp(name, help string) Counter {
+	c.helpM.Lock()
+	c.counterHelp[name] = help
+	c.helpM.Unlock()
+	return c.GetCounter(name)
+}
+
+// GetMinWithHelp is like GetMin, but additionally registers a help string
+// for the counter to be surfaced as a "# HELP" line by WriteProm.
+func (c *CounterBox) GetMinWithHelp(name, help string) MaxMinValue {
+	c.helpM.Lock()
+	c.minHelp[name] = help
+	c.helpM.Unlock()
+	return c.GetMin(name)
+}
+
+// GetMaxWithHelp is like GetMax, but additionally registers a help string
+// for the counter to be surfaced as a "# HELP" line by WriteProm.
+func (c *CounterBox) GetMaxWithHelp(name, help string) MaxMinValue {
+	c.helpM.Lock()
+	c.maxHelp[name] = help
+	c.helpM.Unlock()
+	return c.GetMax(name)
+}
+
 var tmpl = template.Must(template.New("main").Parse(`== Counters ==
 {{- range .Counters}}
   {{.Name}}: {{.Value}}
@@ -133,16 +266,84 @@ var tmpl = template.Must(template.New("main").Parse(`== Counters ==
 == Max values ==
 {{- range .Max}}
   {{.Name}}: {{.Value}}
+{{- end}}
+== Float counters ==
+{{- range .FloatCounters}}
+  {{.Name}}: {{.Value}}
+{{- end}}
+{{- range .Histograms}}
+== {{.Name}} ==
+  count: {{.Count}}
+  sum: {{.Sum}}
+{{- range .Buckets}}
+  le{{.Bound}}: {{.CumulativeCount}}
+{{- end}}
+{{- end}}
+== Rate counters ==
+{{- range .RateCounters}}
+  {{.Name}}: {{.Value}} ({{.RatePerSecond}}/s)
+{{- end}}
+== Gauges ==
+{{- range .Gauges}}
+  {{.Name}}: {{.Value}}
+{{- end}}
+{{- range .Vecs}}
+== {{.Name}} ==
+{{- range .Entries}}
+  {{.Display}}: {{.Value}}
+{{- end}}
 {{- end -}}
 `))
 
+// vecDisplayEntry adapts a VecEntry for the WriteTo/String template,
+// pre-formatting its label set.
+type vecDisplayEntry struct {
+	Display string
+	Value   int64
+}
+
+// vecDisplay adapts a *Vec for the WriteTo/String template.
+type vecDisplay struct {
+	Name    string
+	Entries []vecDisplayEntry
+}
+
+// histogramBucketDisplay adapts a single bucket of a HistogramSnapshot
+// for the WriteTo/String template.
+type histogramBucketDisplay struct {
+	Bound           float64
+	CumulativeCount uint64
+}
+
+// histogramDisplay adapts a *Histogram for the WriteTo/String template.
+type histogramDisplay struct {
+	Name    string
+	Count   uint64
+	Sum     float64
+	Buckets []histogramBucketDisplay
+}
+
+func newHistogramDisplay(h *Histogram) histogramDisplay {
+	snap := h.snapshot()
+	d := histogramDisplay{Name: h.name, Count: snap.Count, Sum: snap.Sum}
+	for i, bound := range snap.Buckets {
+		d.Buckets = append(d.Buckets, histogramBucketDisplay{Bound: bound, CumulativeCount: snap.BucketCounts[i]})
+	}
+	return d
+}
+
 func (c *CounterBox) WriteTo(w io.Writer) {
 	c.m.RLock()
 	defer c.m.RUnlock()
 	data := &struct {
-		Counters []Counter
-		Min      []MaxMinValue
-		Max      []MaxMinValue
+		Counters      []Counter
+		Min           []MaxMinValue
+		Max           []MaxMinValue
+		FloatCounters []FloatCounter
+		Histograms    []histogramDisplay
+		RateCounters  []RateCounter
+		Gauges        []namedValue
+		Vecs          []vecDisplay
 	}{}
 	for _, c := range c.counters {
 		data.Counters = append(data.Counters, c)
@@ -153,9 +354,44 @@ func (c *CounterBox) WriteTo(w io.Writer) {
 	for _, c := range c.max {
 		data.Max = append(data.Max, c)
 	}
+	for _, v := range c.floatCounters {
+		data.FloatCounters = append(data.FloatCounters, v)
+	}
+	for _, v := range c.histograms {
+		data.Histograms = append(data.Histograms, newHistogramDisplay(v))
+	}
+	for _, v := range c.rateCounters {
+		data.RateCounters = append(data.RateCounters, v)
+	}
+	for _, v := range c.gauges {
+		data.Gauges = append(data.Gauges, v)
+	}
+	for _, v := range c.funcGauges {
+		data.Gauges = append(data.Gauges, v)
+	}
+	for _, v := range c.counterVecs {
+		data.Vecs = append(data.Vecs, newVecDisplay(v.Name(), v.Entries()))
+	}
+	for _, v := range c.maxVecs {
+		data.Vecs = append(data.Vecs, newVecDisplay(v.Name(), v.Entries()))
+	}
+	for _, v := range c.minVecs {
+		data.Vecs = append(data.Vecs, newVecDisplay(v.Name(), v.Entries()))
+	}
 	tmpl.Execute(w, data)
 }
 
+func newVecDisplay(name string, entries []VecEntry) vecDisplay {
+	d := vecDisplay{Name: name}
+	for _, e := range entries {
+		d.Entries = append(d.Entries, vecDisplayEntry{
+			Display: formatVecLabels(name, e.Labels),
+			Value:   e.Value,
+		})
+	}
+	return d
+}
+
 func (c *CounterBox) String() string {
 	buf := &bytes.Buffer{}
 	c.WriteTo(buf)
@@ -225,4 +461,4 @@ func (m *minImpl) Name() string {
 
 func (m *minImpl) Value() int64 {
 	return atomic.LoadInt64(&m.value)
-}
\ No newline at end of file
+}
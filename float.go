@@ -0,0 +1,83 @@
+package counters
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// FloatCounter is an interface for a float64 increase-only counter.
+type FloatCounter interface {
+	// Add increases the counter by v. v must be non-negative.
+	Add(v float64) error
+	// Inc increases the counter by one.
+	Inc()
+	// Name returns a name of counter.
+	Name() string
+	// Value returns a current value of counter.
+	Value() float64
+}
+
+// floatCounterImpl tracks its value as two separate atomic fields so the
+// common case of an integral Inc/Add stays a single atomic add: valInt is
+// bumped directly for increments that are whole numbers, while valBits
+// holds the math.Float64bits of everything else, updated through a
+// compare-and-swap loop. Value sums the two.
+type floatCounterImpl struct {
+	name    string
+	valInt  uint64
+	valBits uint64
+}
+
+// Inc increases the counter by one.
+func (f *floatCounterImpl) Inc() {
+	atomic.AddUint64(&f.valInt, 1)
+}
+
+// Add increases the counter by v, returning an error and leaving the
+// counter unchanged if v is negative.
+func (f *floatCounterImpl) Add(v float64) error {
+	if v < 0 {
+		return fmt.Errorf("counters: FloatCounter %q: cannot add negative value %v", f.name, v)
+	}
+	if ival := uint64(v); float64(ival) == v {
+		atomic.AddUint64(&f.valInt, ival)
+		return nil
+	}
+	for {
+		oldBits := atomic.LoadUint64(&f.valBits)
+		newBits := math.Float64bits(math.Float64frombits(oldBits) + v)
+		if atomic.CompareAndSwapUint64(&f.valBits, oldBits, newBits) {
+			return nil
+		}
+	}
+}
+
+// Name returns a name of counter.
+func (f *floatCounterImpl) Name() string {
+	return f.name
+}
+
+// Value returns a current value of counter.
+func (f *floatCounterImpl) Value() float64 {
+	return float64(atomic.LoadUint64(&f.valInt)) + math.Float64frombits(atomic.LoadUint64(&f.valBits))
+}
+
+// GetFloatCounter returns a float counter of given name, if doesn't
+// exist than create.
+func (c *CounterBox) GetFloatCounter(name string) FloatCounter {
+	c.m.RLock()
+	if v, ok := c.floatCounters[name]; ok {
+		c.m.RUnlock()
+		return v
+	}
+	c.m.RUnlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if v, ok := c.floatCounters[name]; ok {
+		return v
+	}
+	v := &floatCounterImpl{name: name}
+	c.floatCounters[name] = v
+	return v
+}
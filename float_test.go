@@ -0,0 +1,39 @@
+package counters
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFloatCounterConcurrentAddInc(t *testing.T) {
+	box := NewCounterBox()
+	f := box.GetFloatCounter("bytes")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Inc()
+			if err := f.Add(0.5); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, want := f.Value(), 150.0; got != want {
+		t.Errorf("Value() = %v, want %v", got, want)
+	}
+}
+
+func TestFloatCounterAddNegative(t *testing.T) {
+	box := NewCounterBox()
+	f := box.GetFloatCounter("neg")
+	if err := f.Add(-1); err == nil {
+		t.Fatal("Add(-1) should have returned an error")
+	}
+	if got := f.Value(); got != 0 {
+		t.Errorf("Value() after rejected Add = %v, want 0", got)
+	}
+}
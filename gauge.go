@@ -0,0 +1,89 @@
+package counters
+
+import "sync/atomic"
+
+// Gauge is an interface for a value that can move up and down freely,
+// unlike Counter (increase only) or MaxMinValue (monotonic extremes).
+type Gauge interface {
+	// Set sets the gauge to v.
+	Set(v int64)
+	// Add increases the gauge by v.
+	Add(v int64)
+	// Sub decreases the gauge by v.
+	Sub(v int64)
+	// Name returns a name of the gauge.
+	Name() string
+	// Value returns a current value of the gauge.
+	Value() int64
+}
+
+type gaugeImpl struct {
+	name  string
+	value int64
+}
+
+func (g *gaugeImpl) Set(v int64) {
+	atomic.StoreInt64(&g.value, v)
+}
+
+func (g *gaugeImpl) Add(v int64) {
+	atomic.AddInt64(&g.value, v)
+}
+
+func (g *gaugeImpl) Sub(v int64) {
+	atomic.AddInt64(&g.value, -v)
+}
+
+func (g *gaugeImpl) Name() string {
+	return g.name
+}
+
+func (g *gaugeImpl) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// funcGauge is a named, read-only gauge whose value is computed on
+// demand by calling fn, e.g. for queue depth or goroutine count. fn must
+// be safe to call concurrently.
+type funcGauge struct {
+	name string
+	fn   func() int64
+}
+
+// Name returns a name of the gauge.
+func (f *funcGauge) Name() string {
+	return f.name
+}
+
+// Value invokes fn and returns its result.
+func (f *funcGauge) Value() int64 {
+	return f.fn()
+}
+
+// GetGauge returns a gauge of given name, if doesn't exist than create.
+func (c *CounterBox) GetGauge(name string) Gauge {
+	c.m.RLock()
+	if v, ok := c.gauges[name]; ok {
+		c.m.RUnlock()
+		return v
+	}
+	c.m.RUnlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if v, ok := c.gauges[name]; ok {
+		return v
+	}
+	v := &gaugeImpl{name: name}
+	c.gauges[name] = v
+	return v
+}
+
+// RegisterFunc registers a lazily-evaluated gauge of given name, whose
+// value is computed by calling fn at read time (by the HTTP handler,
+// WriteTo and the Prometheus exposition). fn must be safe to call
+// concurrently.
+func (c *CounterBox) RegisterFunc(name string, fn func() int64) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.funcGauges[name] = &funcGauge{name: name, fn: fn}
+}
@@ -0,0 +1,50 @@
+package counters
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGaugeSetAddSub(t *testing.T) {
+	box := NewCounterBox()
+	g := box.GetGauge("queue_depth")
+
+	g.Set(10)
+	g.Add(5)
+	g.Sub(3)
+	if got := g.Value(); got != 12 {
+		t.Fatalf("Value() = %d, want 12", got)
+	}
+}
+
+func TestGaugeConcurrentAddSub(t *testing.T) {
+	box := NewCounterBox()
+	g := box.GetGauge("concurrent")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	if got := g.Value(); got != 100 {
+		t.Errorf("Value() = %d, want 100", got)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	box := NewCounterBox()
+	var depth int64 = 7
+	box.RegisterFunc("queue_depth", func() int64 { return depth })
+
+	var buf strings.Builder
+	box.WriteProm(&buf)
+	if !strings.Contains(buf.String(), "queue_depth 7\n") {
+		t.Errorf("WriteProm output missing registered func gauge value, got:\n%s", buf.String())
+	}
+}
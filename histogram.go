@@ -0,0 +1,181 @@
+package counters
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// histogramCounts holds one generation's worth of observations: a running
+// sum (as raw float64 bits, mutated through a CAS loop), a count of
+// observations, and non-cumulative per-bucket counts (snapshot turns
+// them into cumulative ones).
+type histogramCounts struct {
+	sumBits uint64
+	count   uint64
+	buckets []uint64
+}
+
+// Histogram is a lock-free, concurrently observable histogram. Observe
+// never blocks: it picks whichever of two internal histogramCounts is
+// currently "hot" via the top bit of countAndHotIdx (whose low 63 bits
+// double as a running count of every Observe call ever made) and updates
+// it with plain atomic ops. Snapshotting flips the hot bit, waits for the
+// now-cold generation's count to catch up to the count recorded at flip
+// time (proving every in-flight observation targeting it has finished),
+// reads it, merges it into the new hot generation, and zeroes it so it's
+// ready for its next tenure as hot.
+type Histogram struct {
+	name        string
+	upperBounds []float64
+
+	countAndHotIdx uint64
+	counts         [2]*histogramCounts
+
+	writeMtx sync.Mutex
+}
+
+func newHistogram(name string, buckets []float64) *Histogram {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	h := &Histogram{name: name, upperBounds: bounds}
+	h.counts[0] = &histogramCounts{buckets: make([]uint64, len(bounds))}
+	h.counts[1] = &histogramCounts{buckets: make([]uint64, len(bounds))}
+	return h
+}
+
+// Name returns the name of the histogram.
+func (h *Histogram) Name() string {
+	return h.name
+}
+
+// Observe records v in the histogram.
+func (h *Histogram) Observe(v float64) {
+	bucket := sort.SearchFloat64s(h.upperBounds, v)
+
+	n := atomic.AddUint64(&h.countAndHotIdx, 1)
+	hot := h.counts[n>>63]
+
+	if bucket < len(hot.buckets) {
+		atomic.AddUint64(&hot.buckets[bucket], 1)
+	}
+	for {
+		oldBits := atomic.LoadUint64(&hot.sumBits)
+		newBits := math.Float64bits(math.Float64frombits(oldBits) + v)
+		if atomic.CompareAndSwapUint64(&hot.sumBits, oldBits, newBits) {
+			break
+		}
+	}
+	// Incremented last: this is the signal a snapshot waits on to know
+	// the observation has fully landed.
+	atomic.AddUint64(&hot.count, 1)
+}
+
+// HistogramSnapshot is a consistent point-in-time view of a Histogram.
+type HistogramSnapshot struct {
+	Count   uint64
+	Sum     float64
+	Buckets []float64 // upper bounds, parallel to BucketCounts
+	// BucketCounts holds cumulative counts: BucketCounts[i] is the number
+	// of observations <= Buckets[i].
+	BucketCounts []uint64
+}
+
+// snapshot flips the hot generation, waits for the new cold generation to
+// drain, merges it into the new hot generation and returns a consistent
+// snapshot of the totals.
+func (h *Histogram) snapshot() HistogramSnapshot {
+	h.writeMtx.Lock()
+	defer h.writeMtx.Unlock()
+
+	n := atomic.AddUint64(&h.countAndHotIdx, 1<<63)
+	count := n & ((1 << 63) - 1)
+	hot := h.counts[n>>63]
+	cold := h.counts[(^n)>>63]
+
+	for atomic.LoadUint64(&cold.count) != count {
+		runtime.Gosched()
+	}
+
+	snap := HistogramSnapshot{
+		Count:        atomic.LoadUint64(&cold.count),
+		Sum:          math.Float64frombits(atomic.LoadUint64(&cold.sumBits)),
+		Buckets:      append([]float64(nil), h.upperBounds...),
+		BucketCounts: make([]uint64, len(cold.buckets)),
+	}
+	var running uint64
+	for i := range cold.buckets {
+		running += atomic.LoadUint64(&cold.buckets[i])
+		snap.BucketCounts[i] = running
+	}
+
+	atomic.AddUint64(&hot.count, cold.count)
+	for {
+		oldBits := atomic.LoadUint64(&hot.sumBits)
+		newBits := math.Float64bits(math.Float64frombits(oldBits) + snap.Sum)
+		if atomic.CompareAndSwapUint64(&hot.sumBits, oldBits, newBits) {
+			break
+		}
+	}
+	for i := range hot.buckets {
+		atomic.AddUint64(&hot.buckets[i], cold.buckets[i])
+	}
+
+	atomic.StoreUint64(&cold.count, 0)
+	atomic.StoreUint64(&cold.sumBits, 0)
+	for i := range cold.buckets {
+		atomic.StoreUint64(&cold.buckets[i], 0)
+	}
+
+	return snap
+}
+
+// Count returns the total number of observations made so far. It reads
+// countAndHotIdx's running count directly rather than going through
+// snapshot, so it doesn't force a generation flip or drain.
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.countAndHotIdx) & ((1 << 63) - 1)
+}
+
+// Sum returns the sum of all observed values so far. It reads both
+// generations' sums directly rather than going through snapshot, so it
+// doesn't force a generation flip or drain; use snapshot (via the
+// exposition writers) when a fully consistent, per-bucket view is
+// needed.
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&h.counts[0].sumBits)) +
+		math.Float64frombits(atomic.LoadUint64(&h.counts[1].sumBits))
+}
+
+// GetHistogram returns the histogram of given name and buckets, if it
+// doesn't exist than create. buckets are the upper bounds of each
+// bucket; a final +Inf bucket (equal to the overall count) is implied.
+func (c *CounterBox) GetHistogram(name string, buckets []float64) *Histogram {
+	c.m.RLock()
+	if v, ok := c.histograms[name]; ok {
+		c.m.RUnlock()
+		return v
+	}
+	c.m.RUnlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if v, ok := c.histograms[name]; ok {
+		return v
+	}
+	v := newHistogram(name, buckets)
+	c.histograms[name] = v
+	return v
+}
+
+func writeHistogramPlain(w io.Writer, h *Histogram) {
+	snap := h.snapshot()
+	fmt.Fprintf(w, "%s_count=%d\n", h.name, snap.Count)
+	fmt.Fprintf(w, "%s_sum=%v\n", h.name, snap.Sum)
+	for i, bound := range snap.Buckets {
+		fmt.Fprintf(w, "%s_bucket_le%v=%d\n", h.name, bound, snap.BucketCounts[i])
+	}
+}
@@ -0,0 +1,69 @@
+package counters
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHistogramBucketsAndSnapshot(t *testing.T) {
+	box := NewCounterBox()
+	h := box.GetHistogram("latency", []float64{1, 5, 10})
+
+	for _, v := range []float64{0.5, 2, 2, 6, 20} {
+		h.Observe(v)
+	}
+
+	snap := h.snapshot()
+	if snap.Count != 5 {
+		t.Fatalf("Count = %d, want 5", snap.Count)
+	}
+	if want := 0.5 + 2 + 2 + 6 + 20; snap.Sum != want {
+		t.Fatalf("Sum = %v, want %v", snap.Sum, want)
+	}
+	want := []uint64{1, 3, 4} // cumulative counts for <=1, <=5, <=10
+	for i, w := range want {
+		if snap.BucketCounts[i] != w {
+			t.Errorf("BucketCounts[%d] = %d, want %d", i, snap.BucketCounts[i], w)
+		}
+	}
+}
+
+func TestHistogramCountSumDoNotFlip(t *testing.T) {
+	box := NewCounterBox()
+	h := box.GetHistogram("sizes", []float64{10, 100})
+
+	h.Observe(1)
+	h.Observe(50)
+
+	if got := h.Count(); got != 2 {
+		t.Errorf("Count() = %d, want 2", got)
+	}
+	if got := h.Sum(); got != 51 {
+		t.Errorf("Sum() = %v, want 51", got)
+	}
+	// Count/Sum must not have drained the pending observations into a
+	// cold generation: a subsequent snapshot should still see them.
+	snap := h.snapshot()
+	if snap.Count != 2 {
+		t.Errorf("snapshot().Count = %d, want 2 (Count/Sum should not force a flip)", snap.Count)
+	}
+}
+
+func TestHistogramConcurrentObserve(t *testing.T) {
+	box := NewCounterBox()
+	h := box.GetHistogram("concurrent", []float64{1, 2, 3})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h.Observe(float64(i % 4))
+		}(i)
+	}
+	wg.Wait()
+
+	if got := h.Count(); got != 200 {
+		t.Errorf("Count() = %d, want 200", got)
+	}
+}
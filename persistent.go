@@ -0,0 +1,302 @@
+package counters
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Persistent counter files are a small fixed header followed by a flat
+// table of fixed-size slots, modeled loosely on Go's internal/counter
+// package: each slot holds a kind byte, an 8-byte-aligned int64 cell
+// mutated directly in the mapped region with atomic.AddInt64, and the
+// counter's name. New names are appended to the first free slot under a
+// file lock; existing names are found by a linear scan done once at open
+// time and cached in memory.
+const (
+	persistMagic     = "GOCTRS01"
+	persistVersion   = uint32(1)
+	persistHeaderLen = 64
+	persistSlotLen   = 72
+	persistNameLen   = 47
+	persistDefaultN  = 1024
+
+	persistKindNone    = uint8(0)
+	persistKindCounter = uint8(1)
+	persistKindMin     = uint8(2)
+	persistKindMax     = uint8(3)
+)
+
+// persistentConfig holds the options accepted by NewPersistentCounterBox.
+type persistentConfig struct {
+	capacity int
+}
+
+// PersistentOption configures a PersistentCounterBox at construction time.
+type PersistentOption func(*persistentConfig)
+
+// WithCapacity sets the maximum number of distinct counters a newly
+// created persistent file can hold. Ignored when opening an existing
+// file, whose capacity is read from its header. Defaults to 1024.
+func WithCapacity(capacity int) PersistentOption {
+	return func(cfg *persistentConfig) {
+		cfg.capacity = capacity
+	}
+}
+
+// PersistentCounterBox is a CounterBox-like store whose counters are
+// backed by a memory-mapped file, so their values survive process
+// restarts and crashes. Unlike CounterBox it only supports Counter,
+// MaxMinValue and their plain "Get*" constructors, since vectors,
+// histograms and the like have no fixed-width representation to mmap.
+type PersistentCounterBox struct {
+	path string
+	cap  int
+
+	// mu guards mmf and cells directly (getOrCreate, Close, Rotate take
+	// it for writing), and indirectly synchronizes every persistentCell
+	// op against Rotate: cell ops hold it for reading, so Rotate's
+	// freeze-then-unmap can only run once every op in flight when Rotate
+	// was called has finished touching the old mapping, and no new op
+	// can dereference a cell's pointer until Rotate has either left it
+	// alone or frozen it.
+	mu    sync.RWMutex
+	mmf   *mmappedFile
+	cells map[string]*persistentCell
+}
+
+// persistentCell is a handle onto one slot of a persistent file. value
+// points directly into the mapped region, so atomic ops on it are
+// visible across process restarts once flushed. Rotate unmaps the file
+// out from under any cell still holding a pointer into it, so every op
+// takes the owning box's mu for reading: once frozen, target returns a
+// process-local cell instead of the now-invalid mapped pointer, and mu
+// rules out a concurrent op racing the freeze-then-unmap itself.
+type persistentCell struct {
+	name  string
+	kind  uint8
+	value *int64
+	mu    *sync.RWMutex // the owning box's mu
+
+	stale  int32 // atomic; 1 once Rotate has unmapped value's backing file
+	frozen int64 // atomic; process-local value once stale
+}
+
+// freeze snapshots the cell's current value and marks it stale, so that
+// ops issued through this handle after a Rotate operate on a
+// process-local copy instead of aliasing into a mapping Rotate is about
+// to munmap. Callers must hold the owning box's mu for writing and call
+// this before closing the old mapping.
+func (c *persistentCell) freeze() {
+	atomic.StoreInt64(&c.frozen, atomic.LoadInt64(c.value))
+	atomic.StoreInt32(&c.stale, 1)
+}
+
+// target returns the int64 cell ops should act on: the live mapped value
+// normally, or the frozen, process-local snapshot once freeze has run.
+// Callers must hold c.mu for reading for as long as they keep using the
+// returned pointer, so a concurrent Rotate can't unmap it out from under
+// them.
+func (c *persistentCell) target() *int64 {
+	if atomic.LoadInt32(&c.stale) != 0 {
+		return &c.frozen
+	}
+	return c.value
+}
+
+// NewPersistentCounterBox opens (or creates) path as a memory-mapped
+// counter file and returns a box backed by it.
+func NewPersistentCounterBox(path string, opts ...PersistentOption) (*PersistentCounterBox, error) {
+	cfg := persistentConfig{capacity: persistDefaultN}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	mmf, created, err := openMmappedFile(path, cfg.capacity)
+	if err != nil {
+		return nil, fmt.Errorf("counters: opening persistent file %q: %w", path, err)
+	}
+	if !created {
+		if err := mmf.validateHeader(); err != nil {
+			mmf.Close()
+			return nil, err
+		}
+	}
+
+	box := &PersistentCounterBox{
+		path:  path,
+		cap:   mmf.capacity(),
+		mmf:   mmf,
+		cells: make(map[string]*persistentCell),
+	}
+	box.loadExistingCells()
+	return box, nil
+}
+
+func (b *PersistentCounterBox) loadExistingCells() {
+	for i := 0; i < b.cap; i++ {
+		kind, name, value := b.mmf.slot(i)
+		if kind == persistKindNone {
+			continue
+		}
+		b.cells[name] = &persistentCell{name: name, kind: kind, value: value, mu: &b.mu}
+	}
+}
+
+// GetCounter returns a persistent counter of given name, if it doesn't
+// exist than create and append it to the file.
+func (b *PersistentCounterBox) GetCounter(name string) (Counter, error) {
+	c, err := b.getOrCreate(name, persistKindCounter)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetMin returns a persistent minima counter of given name, if it
+// doesn't exist than create and append it to the file.
+func (b *PersistentCounterBox) GetMin(name string) (MaxMinValue, error) {
+	c, err := b.getOrCreate(name, persistKindMin)
+	if err != nil {
+		return nil, err
+	}
+	return minPersistentCell{c}, nil
+}
+
+// GetMax returns a persistent maxima counter of given name, if it
+// doesn't exist than create and append it to the file.
+func (b *PersistentCounterBox) GetMax(name string) (MaxMinValue, error) {
+	c, err := b.getOrCreate(name, persistKindMax)
+	if err != nil {
+		return nil, err
+	}
+	return maxPersistentCell{c}, nil
+}
+
+func (b *PersistentCounterBox) getOrCreate(name string, kind uint8) (*persistentCell, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.cells[name]; ok {
+		if c.kind != kind {
+			return nil, fmt.Errorf("counters: %q already registered with a different kind", name)
+		}
+		return c, nil
+	}
+	if len(name) > persistNameLen {
+		return nil, fmt.Errorf("counters: name %q longer than %d bytes", name, persistNameLen)
+	}
+	value, err := b.mmf.appendSlot(kind, name)
+	if err != nil {
+		return nil, err
+	}
+	c := &persistentCell{name: name, kind: kind, value: value, mu: &b.mu}
+	b.cells[name] = c
+	return c, nil
+}
+
+// Flush forces pending counter updates out to disk.
+func (b *PersistentCounterBox) Flush() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.mmf.sync()
+}
+
+// Close flushes and releases the underlying file.
+func (b *PersistentCounterBox) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.mmf.Close()
+}
+
+// Rotate atomically renames the current file aside (suffixed with the
+// current Unix nanosecond timestamp, for an upload/collector pipeline to
+// pick up) and starts a fresh, empty one in its place. Cells handed out
+// by GetCounter/GetMin/GetMax before the rotation are frozen to a
+// process-local snapshot of their last value rather than left pointing
+// into the unmapped old file: callers should re-fetch their handles from
+// the box after rotating.
+func (b *PersistentCounterBox) Rotate() (rotatedPath string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, c := range b.cells {
+		c.freeze()
+	}
+	if err := b.mmf.Close(); err != nil {
+		return "", err
+	}
+	rotatedPath = fmt.Sprintf("%s.%d", b.path, time.Now().UnixNano())
+	if err := os.Rename(b.path, rotatedPath); err != nil {
+		return "", err
+	}
+	mmf, _, err := openMmappedFile(b.path, b.cap)
+	if err != nil {
+		return "", err
+	}
+	b.mmf = mmf
+	b.cells = make(map[string]*persistentCell)
+	return rotatedPath, nil
+}
+
+func (c *persistentCell) Increment() {
+	c.IncrementBy(1)
+}
+
+func (c *persistentCell) IncrementBy(num int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	atomic.AddInt64(c.target(), int64(num))
+}
+
+func (c *persistentCell) Name() string {
+	return c.name
+}
+
+func (c *persistentCell) Value() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return atomic.LoadInt64(c.target())
+}
+
+// maxPersistentCell adapts a *persistentCell to MaxMinValue with
+// maxima Set semantics.
+type maxPersistentCell struct{ *persistentCell }
+
+func (c maxPersistentCell) Set(v int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v64 := int64(v)
+	target := c.target()
+	for {
+		old := atomic.LoadInt64(target)
+		if v64 <= old {
+			return
+		}
+		if atomic.CompareAndSwapInt64(target, old, v64) {
+			return
+		}
+	}
+}
+
+// minPersistentCell adapts a *persistentCell to MaxMinValue with
+// minima Set semantics.
+type minPersistentCell struct{ *persistentCell }
+
+func (c minPersistentCell) Set(v int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v64 := int64(v)
+	target := c.target()
+	for {
+		old := atomic.LoadInt64(target)
+		if v64 >= old {
+			return
+		}
+		if atomic.CompareAndSwapInt64(target, old, v64) {
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+//go:build !unix
+
+package counters
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// mmappedFile is the pure-Go fallback backing store for a
+// PersistentCounterBox on platforms without mmap: the whole file is read
+// into memory once at open time and is written back out wholesale by
+// sync()/Close(). Slot values still support atomic.AddInt64 (the slice
+// backing them is ordinary process memory), but updates are only
+// visible to other processes after the next successful sync.
+type mmappedFile struct {
+	f    *os.File
+	data []byte
+	cap_ int
+}
+
+func openMmappedFile(path string, capacity int) (*mmappedFile, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+
+	created := fi.Size() == 0
+	size := persistHeaderLen + capacity*persistSlotLen
+	var data []byte
+	if created {
+		data = make([]byte, size)
+		if _, err := f.WriteAt(data, 0); err != nil {
+			f.Close()
+			return nil, false, err
+		}
+	} else {
+		data = make([]byte, fi.Size())
+		if _, err := io.ReadFull(f, data); err != nil {
+			f.Close()
+			return nil, false, err
+		}
+	}
+
+	m := &mmappedFile{f: f, data: data}
+	if created {
+		m.cap_ = capacity
+		m.writeHeader()
+	} else {
+		m.cap_ = int(binary.LittleEndian.Uint32(data[12:16]))
+	}
+	return m, created, nil
+}
+
+func (m *mmappedFile) writeHeader() {
+	copy(m.data[0:8], persistMagic)
+	binary.LittleEndian.PutUint32(m.data[8:12], persistVersion)
+	binary.LittleEndian.PutUint32(m.data[12:16], uint32(m.cap_))
+	binary.LittleEndian.PutUint32(m.data[16:20], 0)
+}
+
+func (m *mmappedFile) validateHeader() error {
+	if string(m.data[0:8]) != persistMagic {
+		return fmt.Errorf("counters: not a counters persistent file (bad magic)")
+	}
+	if version := binary.LittleEndian.Uint32(m.data[8:12]); version != persistVersion {
+		return fmt.Errorf("counters: unsupported persistent file format version %d", version)
+	}
+	return nil
+}
+
+func (m *mmappedFile) capacity() int { return m.cap_ }
+
+func (m *mmappedFile) slotOffset(i int) int { return persistHeaderLen + i*persistSlotLen }
+
+func (m *mmappedFile) slot(i int) (kind uint8, name string, value *int64) {
+	off := m.slotOffset(i)
+	kind = m.data[off]
+	value = (*int64)(unsafe.Pointer(&m.data[off+8]))
+	if kind == persistKindNone {
+		return kind, "", value
+	}
+	nameLen := binary.LittleEndian.Uint16(m.data[off+16 : off+18])
+	name = string(m.data[off+24 : off+24+int(nameLen)])
+	return kind, name, value
+}
+
+// appendSlot claims the first free slot for name. There is no
+// cross-process file lock in this fallback: callers are expected to be
+// a single process, consistent with there being no shared mapping to
+// race over.
+func (m *mmappedFile) appendSlot(kind uint8, name string) (*int64, error) {
+	for i := 0; i < m.cap_; i++ {
+		k, n, v := m.slot(i)
+		if k != persistKindNone && n == name {
+			return v, nil
+		}
+	}
+	for i := 0; i < m.cap_; i++ {
+		off := m.slotOffset(i)
+		if m.data[off] == persistKindNone {
+			for j := 0; j < persistNameLen+1; j++ {
+				m.data[off+24+j] = 0
+			}
+			copy(m.data[off+24:off+24+persistNameLen], name)
+			binary.LittleEndian.PutUint16(m.data[off+16:off+18], uint16(len(name)))
+			m.data[off] = kind
+			return (*int64)(unsafe.Pointer(&m.data[off+8])), nil
+		}
+	}
+	return nil, fmt.Errorf("counters: persistent file %q is full (capacity %d)", m.f.Name(), m.cap_)
+}
+
+func (m *mmappedFile) sync() error {
+	_, err := m.f.WriteAt(m.data, 0)
+	if err != nil {
+		return err
+	}
+	return m.f.Sync()
+}
+
+func (m *mmappedFile) Close() error {
+	syncErr := m.sync()
+	if err := m.f.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
@@ -0,0 +1,146 @@
+//go:build unix
+
+package counters
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// msSync is the platform's MS_SYNC flag for msync(2).
+const msSync = syscall.MS_SYNC
+
+// mmappedFile is the real, memory-mapped backing store for a
+// PersistentCounterBox on unix platforms: its header and slot table live
+// directly in a shared mapping of the file, so atomic ops on a slot's
+// value are visible to every process with it mapped and survive a
+// process crash once synced to disk.
+type mmappedFile struct {
+	f    *os.File
+	data []byte
+	cap_ int
+}
+
+func openMmappedFile(path string, capacity int) (*mmappedFile, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+
+	created := fi.Size() == 0
+	size := int64(persistHeaderLen + capacity*persistSlotLen)
+	if created {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			return nil, false, err
+		}
+	} else {
+		size = fi.Size()
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+
+	m := &mmappedFile{f: f, data: data}
+	if created {
+		m.cap_ = capacity
+		m.writeHeader()
+	} else {
+		m.cap_ = int(binary.LittleEndian.Uint32(data[12:16]))
+	}
+	return m, created, nil
+}
+
+func (m *mmappedFile) writeHeader() {
+	copy(m.data[0:8], persistMagic)
+	binary.LittleEndian.PutUint32(m.data[8:12], persistVersion)
+	binary.LittleEndian.PutUint32(m.data[12:16], uint32(m.cap_))
+	binary.LittleEndian.PutUint32(m.data[16:20], 0)
+}
+
+func (m *mmappedFile) validateHeader() error {
+	if string(m.data[0:8]) != persistMagic {
+		return fmt.Errorf("counters: not a counters persistent file (bad magic)")
+	}
+	if version := binary.LittleEndian.Uint32(m.data[8:12]); version != persistVersion {
+		return fmt.Errorf("counters: unsupported persistent file format version %d", version)
+	}
+	return nil
+}
+
+func (m *mmappedFile) capacity() int { return m.cap_ }
+
+func (m *mmappedFile) slotOffset(i int) int { return persistHeaderLen + i*persistSlotLen }
+
+// slot reads slot i. value is a pointer directly into the mapping, valid
+// for atomic use for as long as the file stays mapped.
+func (m *mmappedFile) slot(i int) (kind uint8, name string, value *int64) {
+	off := m.slotOffset(i)
+	kind = m.data[off]
+	value = (*int64)(unsafe.Pointer(&m.data[off+8]))
+	if kind == persistKindNone {
+		return kind, "", value
+	}
+	nameLen := binary.LittleEndian.Uint16(m.data[off+16 : off+18])
+	name = string(m.data[off+24 : off+24+int(nameLen)])
+	return kind, name, value
+}
+
+// appendSlot claims the first free slot for name under the file lock,
+// first re-checking that no other process registered it in the meantime.
+func (m *mmappedFile) appendSlot(kind uint8, name string) (*int64, error) {
+	if err := syscall.Flock(int(m.f.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+	defer syscall.Flock(int(m.f.Fd()), syscall.LOCK_UN)
+
+	for i := 0; i < m.cap_; i++ {
+		k, n, v := m.slot(i)
+		if k != persistKindNone && n == name {
+			return v, nil
+		}
+	}
+	for i := 0; i < m.cap_; i++ {
+		off := m.slotOffset(i)
+		if m.data[off] == persistKindNone {
+			for j := 0; j < persistNameLen+1; j++ {
+				m.data[off+24+j] = 0
+			}
+			copy(m.data[off+24:off+24+persistNameLen], name)
+			binary.LittleEndian.PutUint16(m.data[off+16:off+18], uint16(len(name)))
+			m.data[off] = kind // written last: it's the slot's presence marker
+			return (*int64)(unsafe.Pointer(&m.data[off+8])), nil
+		}
+	}
+	return nil, fmt.Errorf("counters: persistent file %q is full (capacity %d)", m.f.Name(), m.cap_)
+}
+
+func (m *mmappedFile) sync() error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&m.data[0])), uintptr(len(m.data)), uintptr(msSync))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (m *mmappedFile) Close() error {
+	syncErr := m.sync()
+	if err := syscall.Munmap(m.data); err != nil {
+		return err
+	}
+	if err := m.f.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
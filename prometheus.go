@@ -0,0 +1,138 @@
+package counters
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// promInvalidChars matches any character not allowed in a Prometheus
+// metric name.
+var promInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// sanitizePromName rewrites name into a valid Prometheus metric
+// identifier: disallowed characters become underscores, and a leading
+// digit gets an underscore prefix.
+func sanitizePromName(name string) string {
+	name = promInvalidChars.ReplaceAllString(name, "_")
+	if name == "" {
+		return "_"
+	}
+	if c := name[0]; !(c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+		name = "_" + name
+	}
+	return name
+}
+
+// promName builds the fully qualified, sanitized metric name for name,
+// applying the box's namespace and subsystem prefixes if set.
+func (c *CounterBox) promName(name string) string {
+	parts := make([]string, 0, 3)
+	if c.namespace != "" {
+		parts = append(parts, c.namespace)
+	}
+	if c.subsystem != "" {
+		parts = append(parts, c.subsystem)
+	}
+	parts = append(parts, name)
+	return sanitizePromName(strings.Join(parts, "_"))
+}
+
+// CreatePrometheusHandler creates a handler serving every counter, minima
+// and maxima in the Prometheus text exposition format, so the box can be
+// wired up as a /metrics endpoint without pulling in client_golang.
+func (c *CounterBox) CreatePrometheusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		c.WriteProm(w)
+	}
+}
+
+// WriteProm writes every metric in the box to w in the Prometheus text
+// exposition format. Counters, minima, maxima and gauges get a "# HELP"
+// line (if a help string was registered), a "# TYPE" line, and a
+// "name value" line; counters are typed as "counter", minima, maxima and
+// gauges as "gauge". Float counters, histograms (as _bucket/_sum/_count),
+// rate counters (plus a "<name>_rate" gauge) and vectors (one labeled
+// line per child) follow with their own "# TYPE" lines.
+func (c *CounterBox) WriteProm(w io.Writer) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	c.helpM.RLock()
+	defer c.helpM.RUnlock()
+
+	for k, v := range c.counters {
+		c.writePromLine(w, k, "counter", c.counterHelp[k], v.Value())
+	}
+	for k, v := range c.min {
+		c.writePromLine(w, k, "gauge", c.minHelp[k], v.Value())
+	}
+	for k, v := range c.max {
+		c.writePromLine(w, k, "gauge", c.maxHelp[k], v.Value())
+	}
+	for k, v := range c.floatCounters {
+		promName := c.promName(k)
+		fmt.Fprintf(w, "# TYPE %s counter\n", promName)
+		fmt.Fprintf(w, "%s %v\n", promName, v.Value())
+	}
+	for _, v := range c.histograms {
+		c.writePromHistogram(w, v)
+	}
+	for k, v := range c.rateCounters {
+		c.writePromLine(w, k, "counter", c.counterHelp[k], v.Value())
+		rateName := c.promName(k) + "_rate"
+		fmt.Fprintf(w, "# TYPE %s gauge\n", rateName)
+		fmt.Fprintf(w, "%s %v\n", rateName, v.RatePerSecond())
+	}
+	for k, v := range c.gauges {
+		c.writePromLine(w, k, "gauge", "", v.Value())
+	}
+	for k, v := range c.funcGauges {
+		c.writePromLine(w, k, "gauge", "", v.Value())
+	}
+	for _, v := range c.counterVecs {
+		c.writePromVec(w, v.Name(), "counter", v.Entries())
+	}
+	for _, v := range c.maxVecs {
+		c.writePromVec(w, v.Name(), "gauge", v.Entries())
+	}
+	for _, v := range c.minVecs {
+		c.writePromVec(w, v.Name(), "gauge", v.Entries())
+	}
+}
+
+// writePromHistogram writes a histogram's buckets, sum and count in the
+// Prometheus text exposition format.
+func (c *CounterBox) writePromHistogram(w io.Writer, h *Histogram) {
+	promName := c.promName(h.Name())
+	snap := h.snapshot()
+	fmt.Fprintf(w, "# TYPE %s histogram\n", promName)
+	for i, bound := range snap.Buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", promName, fmt.Sprintf("%v", bound), snap.BucketCounts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", promName, snap.Count)
+	fmt.Fprintf(w, "%s_sum %v\n", promName, snap.Sum)
+	fmt.Fprintf(w, "%s_count %d\n", promName, snap.Count)
+}
+
+// writePromVec writes a single "# TYPE" line followed by one sample line
+// per child, each carrying its label set as Prometheus curly-brace
+// label pairs.
+func (c *CounterBox) writePromVec(w io.Writer, name, typ string, entries []VecEntry) {
+	promName := c.promName(name)
+	fmt.Fprintf(w, "# TYPE %s %s\n", promName, typ)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s %d\n", formatVecLabels(promName, e.Labels), e.Value)
+	}
+}
+
+func (c *CounterBox) writePromLine(w io.Writer, name, typ, help string, value int64) {
+	promName := c.promName(name)
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", promName, help)
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", promName, typ)
+	fmt.Fprintf(w, "%s %d\n", promName, value)
+}
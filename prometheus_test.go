@@ -0,0 +1,42 @@
+package counters
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWritePromFormat(t *testing.T) {
+	box := NewCounterBox(WithNamespace("myapp"))
+	box.GetCounterWithHelp("reqs", "total requests").IncrementBy(3)
+	box.GetGauge("inflight").Set(5)
+
+	var buf strings.Builder
+	box.WriteProm(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# HELP myapp_reqs total requests\n",
+		"# TYPE myapp_reqs counter\n",
+		"myapp_reqs 3\n",
+		"# TYPE myapp_inflight gauge\n",
+		"myapp_inflight 5\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteProm output missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestSanitizePromName(t *testing.T) {
+	cases := map[string]string{
+		"reqs.count": "reqs_count",
+		"1abc":       "_1abc",
+		"valid_name": "valid_name",
+		"":           "_",
+	}
+	for in, want := range cases {
+		if got := sanitizePromName(in); got != want {
+			t.Errorf("sanitizePromName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package counters
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateSubBuckets is the number of sub-buckets a RateCounter's sliding
+// window is divided into; each sub-bucket covers window/rateSubBuckets
+// of time.
+const rateSubBuckets = 60
+
+// RateCounter is a Counter that additionally tracks how many increments
+// landed within a trailing time window, exposing that as a count and as
+// a per-second rate.
+type RateCounter interface {
+	// Increment increases the counter by one.
+	Increment()
+	// IncrementBy increases the counter by given number.
+	IncrementBy(num int)
+	// Name returns a name of counter.
+	Name() string
+	// Value returns the all-time cumulative value of the counter.
+	Value() int64
+	// CountInWindow returns the number of increments observed within
+	// the trailing window.
+	CountInWindow() int64
+	// RatePerSecond returns CountInWindow divided by the window length,
+	// in seconds.
+	RatePerSecond() float64
+}
+
+// rateCounterImpl tracks its window as a ring of rateSubBuckets counts,
+// rotated lazily whenever the counter is touched: any sub-bucket whose
+// time has fully passed is zeroed and "reclaimed" for reuse, so idle
+// counters cost nothing between accesses.
+type rateCounterImpl struct {
+	name   string
+	window time.Duration
+
+	total int64 // atomic, all-time cumulative count
+
+	mu         sync.Mutex
+	bucketDur  time.Duration
+	buckets    [rateSubBuckets]int64
+	head       int       // index of the current (most recent) sub-bucket
+	headExpiry time.Time // time at which the current sub-bucket rolls over
+}
+
+func newRateCounter(name string, window time.Duration) *rateCounterImpl {
+	bucketDur := window / rateSubBuckets
+	if bucketDur <= 0 {
+		bucketDur = time.Nanosecond
+	}
+	return &rateCounterImpl{
+		name:       name,
+		window:     window,
+		bucketDur:  bucketDur,
+		headExpiry: time.Now().Add(bucketDur),
+	}
+}
+
+// rotate advances the ring so that "head" always refers to the
+// sub-bucket covering now, zeroing every sub-bucket it passes over.
+// Callers must hold r.mu.
+func (r *rateCounterImpl) rotate(now time.Time) {
+	if now.Before(r.headExpiry) {
+		return
+	}
+	elapsed := now.Sub(r.headExpiry) + r.bucketDur
+	steps := int(elapsed / r.bucketDur)
+	if steps > rateSubBuckets {
+		steps = rateSubBuckets
+	}
+	for i := 0; i < steps; i++ {
+		r.head = (r.head + 1) % rateSubBuckets
+		r.buckets[r.head] = 0
+	}
+	r.headExpiry = r.headExpiry.Add(time.Duration(steps) * r.bucketDur)
+	if now.After(r.headExpiry) {
+		r.headExpiry = now.Add(r.bucketDur)
+	}
+}
+
+// Increment increases the counter by one.
+func (r *rateCounterImpl) Increment() {
+	r.IncrementBy(1)
+}
+
+// IncrementBy increases the counter by num.
+func (r *rateCounterImpl) IncrementBy(num int) {
+	atomic.AddInt64(&r.total, int64(num))
+	r.mu.Lock()
+	r.rotate(time.Now())
+	r.buckets[r.head] += int64(num)
+	r.mu.Unlock()
+}
+
+// Name returns a name of counter.
+func (r *rateCounterImpl) Name() string {
+	return r.name
+}
+
+// Value returns the all-time cumulative value of the counter.
+func (r *rateCounterImpl) Value() int64 {
+	return atomic.LoadInt64(&r.total)
+}
+
+// CountInWindow returns the number of increments observed within the
+// trailing window.
+func (r *rateCounterImpl) CountInWindow() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotate(time.Now())
+	var sum int64
+	for _, v := range r.buckets {
+		sum += v
+	}
+	return sum
+}
+
+// RatePerSecond returns CountInWindow divided by the window length, in
+// seconds.
+func (r *rateCounterImpl) RatePerSecond() float64 {
+	return float64(r.CountInWindow()) / r.window.Seconds()
+}
+
+// GetRate returns a rate counter of given name and trailing window, if
+// doesn't exist than create.
+func (c *CounterBox) GetRate(name string, window time.Duration) RateCounter {
+	c.m.RLock()
+	if v, ok := c.rateCounters[name]; ok {
+		c.m.RUnlock()
+		return v
+	}
+	c.m.RUnlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if v, ok := c.rateCounters[name]; ok {
+		return v
+	}
+	v := newRateCounter(name, window)
+	c.rateCounters[name] = v
+	return v
+}
@@ -0,0 +1,50 @@
+package counters
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateCounterWindowRotation(t *testing.T) {
+	box := NewCounterBox()
+	r := box.GetRate("events", 100*time.Millisecond)
+
+	r.IncrementBy(5)
+	if got := r.Value(); got != 5 {
+		t.Fatalf("Value() = %d, want 5", got)
+	}
+	if got := r.CountInWindow(); got != 5 {
+		t.Fatalf("CountInWindow() = %d, want 5", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if got := r.CountInWindow(); got != 0 {
+		t.Errorf("CountInWindow() after window elapsed = %d, want 0", got)
+	}
+	if got := r.Value(); got != 5 {
+		t.Errorf("Value() after window elapsed = %d, want 5 (all-time total is unaffected)", got)
+	}
+}
+
+func TestRateCounterConcurrentIncrement(t *testing.T) {
+	box := NewCounterBox()
+	r := box.GetRate("concurrent", time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Increment()
+		}()
+	}
+	wg.Wait()
+
+	if got := r.Value(); got != 200 {
+		t.Errorf("Value() = %d, want 200", got)
+	}
+	if got := r.CountInWindow(); got != 200 {
+		t.Errorf("CountInWindow() = %d, want 200", got)
+	}
+}
@@ -0,0 +1,368 @@
+package counters
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// vecShardCount is the number of lock shards a Vec spreads its children
+// across, to keep WithLabelValues cheap under concurrent, differing
+// label tuples.
+const vecShardCount = 32
+
+// VecEntry is a single label-tuple child of a CounterVec, MaxVec or
+// MinVec, paired with its label values for display and exposition.
+type VecEntry struct {
+	Labels map[string]string
+	Value  int64
+}
+
+// vecKey joins label values into a single map/shard key. "\xff" cannot
+// appear in a caller-supplied label value from normal text input, so it
+// is a safe separator.
+func vecKey(vals []string) string {
+	return strings.Join(vals, "\xff")
+}
+
+func vecShardFor(shards []sync.RWMutex, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()) % len(shards)
+}
+
+// escapePromLabelValue escapes s for use inside a double-quoted
+// Prometheus label value: per the text exposition format, only
+// backslash, double quote and newline need escaping (unlike Go's %q,
+// which also backslash-escapes non-printable and non-ASCII runes as
+// \u/\x sequences the Prometheus parser doesn't understand).
+func escapePromLabelValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+func formatVecLabels(name string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = fmt.Sprintf(`%s="%s"`, n, escapePromLabelValue(labels[n]))
+	}
+	return fmt.Sprintf("%s{%s}", name, strings.Join(parts, ","))
+}
+
+func labelMap(labelNames, vals []string) map[string]string {
+	m := make(map[string]string, len(labelNames))
+	for i, n := range labelNames {
+		if i < len(vals) {
+			m[n] = vals[i]
+		}
+	}
+	return m
+}
+
+// discardCounter is returned by WithLabelValues once a Vec's cardinality
+// cap has been reached; it silently drops increments.
+type discardCounter struct{}
+
+func (discardCounter) Increment()      {}
+func (discardCounter) IncrementBy(int) {}
+func (discardCounter) Name() string    { return "" }
+func (discardCounter) Value() int64    { return 0 }
+
+// discardMaxMin is returned by WithLabelValues on a MaxVec/MinVec once
+// the cardinality cap has been reached; it silently drops updates.
+type discardMaxMin struct{}
+
+func (discardMaxMin) Set(int)      {}
+func (discardMaxMin) Name() string { return "" }
+func (discardMaxMin) Value() int64 { return 0 }
+
+// vecChild is the constraint every vec[T] child type must satisfy: just
+// enough to report a value for Entries/exposition. CounterVec, MaxVec
+// and MinVec additionally require their child pointer type to satisfy
+// Counter or MaxMinValue, which WithLabelValues asserts via the concrete
+// T it's instantiated with.
+type vecChild interface {
+	Value() int64
+}
+
+// vec is the sharded label-tuple-to-child store shared by CounterVec,
+// MaxVec and MinVec: the three differ only in the child type they store
+// and in what WithLabelValues hands back, so that part alone stays
+// type-specific.
+type vec[T vecChild] struct {
+	name           string
+	labelNames     []string
+	maxCardinality int
+
+	mus   [vecShardCount]sync.RWMutex
+	items [vecShardCount]map[string][]string // key -> label values, guarded by mus[i]
+	vals  [vecShardCount]map[string]T
+
+	size     int32
+	overflow *counterImpl
+}
+
+func newVec[T vecChild](name string, labelNames []string, maxCardinality int, overflow *counterImpl) *vec[T] {
+	v := &vec[T]{name: name, labelNames: labelNames, maxCardinality: maxCardinality, overflow: overflow}
+	for i := range v.vals {
+		v.items[i] = make(map[string][]string)
+		v.vals[i] = make(map[string]T)
+	}
+	return v
+}
+
+// Name returns the vector's metric name.
+func (v *vec[T]) Name() string { return v.name }
+
+// withLabelValues returns the child for the given tuple of label values,
+// creating it via newChild if this is the first time it's seen. ok is
+// false once the vec's cardinality cap has been reached, in which case
+// the vec's overflow counter has been incremented and callers should
+// return their type's discard value instead of the zero T.
+func (v *vec[T]) withLabelValues(vals []string, newChild func() T) (child T, ok bool) {
+	key := vecKey(vals)
+	shard := vecShardFor(v.mus[:], key)
+
+	v.mus[shard].RLock()
+	if c, ok := v.vals[shard][key]; ok {
+		v.mus[shard].RUnlock()
+		return c, true
+	}
+	v.mus[shard].RUnlock()
+
+	v.mus[shard].Lock()
+	defer v.mus[shard].Unlock()
+	if c, ok := v.vals[shard][key]; ok {
+		return c, true
+	}
+	// Reserve a slot with a CAS so that concurrent inserts into different
+	// shards cannot together exceed the cap.
+	for {
+		n := atomic.LoadInt32(&v.size)
+		if v.maxCardinality > 0 && int(n) >= v.maxCardinality {
+			v.overflow.Increment()
+			var zero T
+			return zero, false
+		}
+		if atomic.CompareAndSwapInt32(&v.size, n, n+1) {
+			break
+		}
+	}
+	c := newChild()
+	v.vals[shard][key] = c
+	v.items[shard][key] = append([]string(nil), vals...)
+	return c, true
+}
+
+// Delete removes the child for the given tuple of label values, if any.
+func (v *vec[T]) Delete(vals ...string) {
+	key := vecKey(vals)
+	shard := vecShardFor(v.mus[:], key)
+	v.mus[shard].Lock()
+	defer v.mus[shard].Unlock()
+	if _, ok := v.vals[shard][key]; ok {
+		delete(v.vals[shard], key)
+		delete(v.items[shard], key)
+		atomic.AddInt32(&v.size, -1)
+	}
+}
+
+// Reset removes all children of the vec.
+func (v *vec[T]) Reset() {
+	for i := range v.mus {
+		v.mus[i].Lock()
+		v.vals[i] = make(map[string]T)
+		v.items[i] = make(map[string][]string)
+		v.mus[i].Unlock()
+	}
+	atomic.StoreInt32(&v.size, 0)
+}
+
+// Entries returns a snapshot of every child, paired with its label set.
+func (v *vec[T]) Entries() []VecEntry {
+	var entries []VecEntry
+	for i := range v.mus {
+		v.mus[i].RLock()
+		for key, c := range v.vals[i] {
+			entries = append(entries, VecEntry{
+				Labels: labelMap(v.labelNames, v.items[i][key]),
+				Value:  c.Value(),
+			})
+		}
+		v.mus[i].RUnlock()
+	}
+	return entries
+}
+
+// CounterVec is a collection of Counters partitioned by a fixed set of
+// label names, one child Counter per distinct tuple of label values.
+type CounterVec struct {
+	*vec[*counterImpl]
+}
+
+func newCounterVec(name string, labelNames []string, maxCardinality int, overflow *counterImpl) *CounterVec {
+	return &CounterVec{newVec[*counterImpl](name, labelNames, maxCardinality, overflow)}
+}
+
+// WithLabelValues returns the Counter for the given tuple of label
+// values (in the order of the vec's label names), creating it if this is
+// the first time it's seen. If the vec's cardinality cap has been
+// reached, a discarding Counter is returned instead and the vec's
+// overflow counter is incremented.
+func (v *CounterVec) WithLabelValues(vals ...string) Counter {
+	c, ok := v.vec.withLabelValues(vals, func() *counterImpl {
+		return &counterImpl{name: v.vec.name, value: 0}
+	})
+	if !ok {
+		return discardCounter{}
+	}
+	return c
+}
+
+// MaxVec is a collection of maxima partitioned by a fixed set of label
+// names, one child maxima per distinct tuple of label values.
+type MaxVec struct {
+	*vec[*maxImpl]
+}
+
+func newMaxVec(name string, labelNames []string, maxCardinality int, overflow *counterImpl) *MaxVec {
+	return &MaxVec{newVec[*maxImpl](name, labelNames, maxCardinality, overflow)}
+}
+
+// WithLabelValues returns the MaxMinValue for the given tuple of label
+// values, creating it if this is the first time it's seen. If the vec's
+// cardinality cap has been reached, a discarding value is returned
+// instead and the vec's overflow counter is incremented.
+func (v *MaxVec) WithLabelValues(vals ...string) MaxMinValue {
+	c, ok := v.vec.withLabelValues(vals, func() *maxImpl {
+		return &maxImpl{name: v.vec.name, value: 0}
+	})
+	if !ok {
+		return discardMaxMin{}
+	}
+	return c
+}
+
+// MinVec is a collection of minima partitioned by a fixed set of label
+// names, one child minima per distinct tuple of label values.
+type MinVec struct {
+	*vec[*minImpl]
+}
+
+func newMinVec(name string, labelNames []string, maxCardinality int, overflow *counterImpl) *MinVec {
+	return &MinVec{newVec[*minImpl](name, labelNames, maxCardinality, overflow)}
+}
+
+// WithLabelValues returns the MaxMinValue for the given tuple of label
+// values, creating it if this is the first time it's seen. If the vec's
+// cardinality cap has been reached, a discarding value is returned
+// instead and the vec's overflow counter is incremented.
+func (v *MinVec) WithLabelValues(vals ...string) MaxMinValue {
+	c, ok := v.vec.withLabelValues(vals, func() *minImpl {
+		return &minImpl{name: v.vec.name, value: 0}
+	})
+	if !ok {
+		return discardMaxMin{}
+	}
+	return c
+}
+
+// GetCounterVec returns the CounterVec of given name and label names, if
+// it doesn't exist it is created with no cardinality cap. Use
+// GetCounterVecWithCap to bound memory growth from user-supplied label
+// values.
+func (c *CounterBox) GetCounterVec(name string, labelNames ...string) *CounterVec {
+	return c.GetCounterVecWithCap(name, 0, labelNames...)
+}
+
+// GetCounterVecWithCap is like GetCounterVec, but caps the number of
+// distinct label-value tuples the vec will track. Once the cap is
+// reached, WithLabelValues for a new tuple returns a discarding Counter
+// and increments "<name>_cardinality_overflow".
+func (c *CounterBox) GetCounterVecWithCap(name string, maxCardinality int, labelNames ...string) *CounterVec {
+	c.m.RLock()
+	if v, ok := c.counterVecs[name]; ok {
+		c.m.RUnlock()
+		return v
+	}
+	c.m.RUnlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if v, ok := c.counterVecs[name]; ok {
+		return v
+	}
+	overflow := c.getOrCreateCounterLocked(name + "_cardinality_overflow")
+	v := newCounterVec(name, labelNames, maxCardinality, overflow)
+	c.counterVecs[name] = v
+	return v
+}
+
+// GetMaxVec returns the MaxVec of given name and label names, if it
+// doesn't exist it is created with no cardinality cap. Use
+// GetMaxVecWithCap to bound memory growth from user-supplied label
+// values.
+func (c *CounterBox) GetMaxVec(name string, labelNames ...string) *MaxVec {
+	return c.GetMaxVecWithCap(name, 0, labelNames...)
+}
+
+// GetMaxVecWithCap is like GetMaxVec, but caps the number of distinct
+// label-value tuples the vec will track. Once the cap is reached,
+// WithLabelValues for a new tuple returns a discarding MaxMinValue and
+// increments "<name>_cardinality_overflow".
+func (c *CounterBox) GetMaxVecWithCap(name string, maxCardinality int, labelNames ...string) *MaxVec {
+	c.m.RLock()
+	if v, ok := c.maxVecs[name]; ok {
+		c.m.RUnlock()
+		return v
+	}
+	c.m.RUnlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if v, ok := c.maxVecs[name]; ok {
+		return v
+	}
+	overflow := c.getOrCreateCounterLocked(name + "_cardinality_overflow")
+	v := newMaxVec(name, labelNames, maxCardinality, overflow)
+	c.maxVecs[name] = v
+	return v
+}
+
+// GetMinVec returns the MinVec of given name and label names, if it
+// doesn't exist it is created with no cardinality cap. Use
+// GetMinVecWithCap to bound memory growth from user-supplied label
+// values.
+func (c *CounterBox) GetMinVec(name string, labelNames ...string) *MinVec {
+	return c.GetMinVecWithCap(name, 0, labelNames...)
+}
+
+// GetMinVecWithCap is like GetMinVec, but caps the number of distinct
+// label-value tuples the vec will track. Once the cap is reached,
+// WithLabelValues for a new tuple returns a discarding MaxMinValue and
+// increments "<name>_cardinality_overflow".
+func (c *CounterBox) GetMinVecWithCap(name string, maxCardinality int, labelNames ...string) *MinVec {
+	c.m.RLock()
+	if v, ok := c.minVecs[name]; ok {
+		c.m.RUnlock()
+		return v
+	}
+	c.m.RUnlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if v, ok := c.minVecs[name]; ok {
+		return v
+	}
+	overflow := c.getOrCreateCounterLocked(name + "_cardinality_overflow")
+	v := newMinVec(name, labelNames, maxCardinality, overflow)
+	c.minVecs[name] = v
+	return v
+}
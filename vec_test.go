@@ -0,0 +1,55 @@
+package counters
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCounterVecConcurrentWithLabelValues(t *testing.T) {
+	box := NewCounterBox()
+	v := box.GetCounterVecWithCap("reqs", 50, "shard")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v.WithLabelValues(fmt.Sprintf("s%d", i%64)).Increment()
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMaxVecMinVecWithLabelValues(t *testing.T) {
+	box := NewCounterBox()
+
+	maxVec := box.GetMaxVec("latency_max", "route")
+	maxVec.WithLabelValues("/a").Set(3)
+	maxVec.WithLabelValues("/a").Set(7)
+	maxVec.WithLabelValues("/a").Set(5)
+	if got := maxVec.WithLabelValues("/a").Value(); got != 7 {
+		t.Errorf("MaxVec Value() = %d, want 7", got)
+	}
+
+	minVec := box.GetMinVec("latency_min", "route")
+	minVec.WithLabelValues("/a").Set(-3)
+	minVec.WithLabelValues("/a").Set(-7)
+	minVec.WithLabelValues("/a").Set(-1)
+	if got := minVec.WithLabelValues("/a").Value(); got != -7 {
+		t.Errorf("MinVec Value() = %d, want -7", got)
+	}
+
+	entries := maxVec.Entries()
+	if len(entries) != 1 || entries[0].Labels["route"] != "/a" || entries[0].Value != 7 {
+		t.Errorf("MaxVec Entries() = %+v, want one entry for route=/a value=7", entries)
+	}
+}
+
+func TestFormatVecLabelsEscaping(t *testing.T) {
+	got := formatVecLabels("reqs", map[string]string{"path": `a"b\c` + "\nd"})
+	want := `reqs{path="a\"b\\c\nd"}`
+	if got != want {
+		t.Errorf("formatVecLabels() = %q, want %q", got, want)
+	}
+}